@@ -0,0 +1,228 @@
+package url2img
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// batchItem represents batch manifest entry
+type batchItem struct {
+	Index  int    `json:"index"`
+	Id     string `json:"id,omitempty"`
+	Url    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	File   string `json:"file,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+
+	data        []byte
+	contentType string
+}
+
+// serveBatch handles /batch requests
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, fmt.Errorf("%w: %s", errMethod, r.Method))
+		return
+	}
+
+	archive := "zip"
+	if r.URL.Query().Get("archive") != "" {
+		archive = r.URL.Query().Get("archive")
+		if archive != "zip" && archive != "tar" && archive != "multipart" {
+			writeError(w, fmt.Errorf("%w: invalid archive %s", errParams, archive))
+			return
+		}
+	}
+
+	var params []Params
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %s", errParams, err.Error()))
+		return
+	}
+
+	if len(params) == 0 {
+		writeError(w, fmt.Errorf("%w: empty batch", errParams))
+		return
+	}
+
+	if len(params) > s.MaxBatch {
+		writeError(w, fmt.Errorf("%w: batch maximum is %d", errParams, s.MaxBatch))
+		return
+	}
+
+	timeout := time.Duration(s.ReadTimeout+s.WriteTimeout) * time.Second
+
+	// batch renders can outlast server write timeout, extend deadline so archive is not cut
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + time.Duration(s.WriteTimeout)*time.Second))
+
+	items := make([]batchItem, len(params))
+
+	var wg sync.WaitGroup
+	for i := range params {
+		p := params[i]
+		items[i] = batchItem{Index: i, Url: p.Url}
+
+		err := p.values()
+		if err != nil {
+			err = fmt.Errorf("%w: %s", errParams, err.Error())
+			items[i].Error = err.Error()
+			items[i].Code = errorCode(err)
+			continue
+		}
+
+		items[i].Id = p.Id
+		items[i].Url = p.Url
+
+		if err := s.checkCallback(p); err != nil {
+			items[i].Error = err.Error()
+			items[i].Code = errorCode(err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(item *batchItem) {
+			defer wg.Done()
+
+			res := s.render(r.Context(), p, timeout)
+
+			data, err := res.Data, res.Err
+			if err == errTimeout {
+				err = fmt.Errorf("%w after %d seconds", errTimeout, s.ReadTimeout+s.WriteTimeout)
+			}
+
+			if p.Callback != "" {
+				go s.callback(p, data, err)
+			}
+
+			item.Status = res.Meta.Status
+
+			if err != nil {
+				item.Error = err.Error()
+				item.Code = errorCode(err)
+				return
+			}
+
+			item.File = fmt.Sprintf("%d.%s", item.Index, formats[p.Format].Extension)
+			item.data = data
+			item.contentType = formats[p.Format].ContentType
+		}(&items[i])
+	}
+	wg.Wait()
+
+	manifest, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch archive {
+	case "zip":
+		writeZip(w, items, manifest)
+	case "tar":
+		writeTar(w, items, manifest)
+	case "multipart":
+		writeMultipart(w, items, manifest)
+	}
+}
+
+// writeZip writes batch images and manifest as zip archive
+func writeZip(w http.ResponseWriter, items []batchItem, manifest []byte) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"batch.zip\"")
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	for _, item := range items {
+		if item.data == nil {
+			continue
+		}
+
+		f, err := zw.Create(item.File)
+		if err != nil {
+			return
+		}
+		f.Write(item.data)
+	}
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return
+	}
+	f.Write(manifest)
+}
+
+// writeTar writes batch images and manifest as tar archive
+func writeTar(w http.ResponseWriter, items []batchItem, manifest []byte) {
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"batch.tar\"")
+	w.WriteHeader(http.StatusOK)
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	now := time.Now()
+
+	for _, item := range items {
+		if item.data == nil {
+			continue
+		}
+
+		err := tw.WriteHeader(&tar.Header{Name: item.File, Mode: 0644, Size: int64(len(item.data)), ModTime: now})
+		if err != nil {
+			return
+		}
+		tw.Write(item.data)
+	}
+
+	err := tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(manifest)), ModTime: now})
+	if err != nil {
+		return
+	}
+	tw.Write(manifest)
+}
+
+// writeMultipart writes batch images and manifest as multipart/mixed response
+func writeMultipart(w http.ResponseWriter, items []batchItem, manifest []byte) {
+	mw := multipart.NewWriter(w)
+	defer mw.Close()
+
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+
+	for _, item := range items {
+		if item.data == nil {
+			continue
+		}
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Type", item.contentType)
+		h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", item.File))
+
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return
+		}
+		part.Write(item.data)
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", "application/json")
+	h.Set("Content-Disposition", "attachment; filename=\"manifest.json\"")
+
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return
+	}
+	part.Write(manifest)
+}
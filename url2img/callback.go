@@ -0,0 +1,163 @@
+package url2img
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Callback headers
+const (
+	CallbackIdHeader        = "X-Url2img-Id"
+	CallbackSignatureHeader = "X-Url2img-Signature"
+)
+
+// callbackError represents json body posted when capture fails
+type callbackError struct {
+	Id    string `json:"id"`
+	Url   string `json:"url"`
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// callback posts image, or json error, to params callback url, retries with exponential backoff
+func (s *Server) callback(p Params, data []byte, err error) {
+	contentType := formats[p.Format].ContentType
+
+	if err != nil {
+		data, err = json.Marshal(callbackError{p.Id, p.Url, err.Error(), errorCode(err)})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Callback %s: %s\n", p.Callback, err.Error())
+			return
+		}
+
+		contentType = "application/json"
+	}
+
+	signature := ""
+	if s.CallbackSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.CallbackSecret))
+		mac.Write(data)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	client := s.client(p)
+	backoff := time.Second
+
+	for i := 0; ; i++ {
+		err = s.post(client, p, data, contentType, signature)
+		if err == nil {
+			return
+		}
+
+		if i >= s.CallbackRetries {
+			fmt.Fprintf(os.Stderr, "Callback %s: %s\n", p.Callback, err.Error())
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// client returns http client for callback, requests are sent through params or server proxy.
+// Without proxy every dialed address is checked with filter, as host can resolve to other address than when it was checked.
+func (s *Server) client(p Params) *http.Client {
+	transport := &http.Transport{
+		TLSHandshakeTimeout: 10 * time.Second,
+		DialContext:         s.dial,
+	}
+
+	proxy := p.Proxy
+	if proxy == "" {
+		proxy = s.Proxy
+	}
+
+	if proxy != "" {
+		if u, err := url.Parse(proxy); err == nil {
+			transport.Proxy = http.ProxyURL(u)
+			transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second}).DialContext
+		}
+	}
+
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       time.Duration(s.WriteTimeout) * time.Second,
+		CheckRedirect: s.checkRedirect,
+	}
+}
+
+// dial dials callback address, address is checked with filter after it is resolved
+func (s *Server) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if s.Filter != nil {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			return s.Filter.CheckAddr(host, net.ParseIP(ip))
+		}
+	}
+
+	return dialer.DialContext(ctx, network, address)
+}
+
+// checkRedirect checks every callback redirect with filter
+func (s *Server) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	if s.Filter != nil {
+		if err := s.Filter.Check(req.URL.String(), true); err != nil {
+			return fmt.Errorf("redirect %w", err)
+		}
+	}
+
+	return nil
+}
+
+// post posts data to params callback url
+func (s *Server) post(client *http.Client, p Params, data []byte, contentType, signature string) error {
+	req, err := http.NewRequest("POST", p.Callback, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", Name, Version))
+	req.Header.Set(CallbackIdHeader, p.Id)
+	if signature != "" {
+		req.Header.Set(CallbackSignatureHeader, signature)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return nil
+}
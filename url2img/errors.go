@@ -0,0 +1,162 @@
+package url2img
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error represents render error with machine readable code and HTTP status, errors form hierarchy, e.g. ErrDNS is ErrNetwork
+type Error struct {
+	Code   string
+	Status int
+
+	msg    string
+	parent *Error
+}
+
+// errorCodes maps error codes to render errors, codes are used to send errors over pipe
+var errorCodes = make(map[string]*Error)
+
+// newError returns new render error and registers its code
+func newError(code string, status int, msg string, parent *Error) *Error {
+	e := &Error{Code: code, Status: status, msg: msg, parent: parent}
+	errorCodes[code] = e
+
+	return e
+}
+
+// Render errors
+var (
+	ErrNetwork     = newError("network_error", http.StatusBadGateway, "target network error", nil)
+	ErrDNS         = newError("dns_failure", http.StatusBadGateway, "target host not found", ErrNetwork)
+	ErrConnRefused = newError("connection_refused", http.StatusBadGateway, "target connection refused", ErrNetwork)
+	ErrTLS         = newError("tls_error", http.StatusBadGateway, "target certificate error", ErrNetwork)
+
+	ErrTarget    = newError("target_error", http.StatusBadGateway, "target error", nil)
+	ErrTarget4xx = newError("target_client_error", http.StatusBadGateway, "target client error", ErrTarget)
+	ErrTarget5xx = newError("target_server_error", http.StatusBadGateway, "target server error", ErrTarget)
+	ErrAuth      = newError("target_auth_failed", http.StatusBadGateway, "target authentication failed", ErrTarget)
+
+	ErrLoadTimeout = newError("load_timeout", http.StatusGatewayTimeout, "page load timeout", nil)
+	ErrWaitTimeout = newError("wait_timeout", http.StatusGatewayTimeout, "wait condition timeout", nil)
+
+	ErrPage     = newError("page_error", http.StatusUnprocessableEntity, "page error", nil)
+	ErrSelector = newError("selector_not_found", http.StatusUnprocessableEntity, "selector not found", ErrPage)
+	ErrScript   = newError("script_failed", http.StatusUnprocessableEntity, "script failed", ErrPage)
+
+	ErrImage         = newError("image_error", http.StatusInternalServerError, "image error", nil)
+	ErrImageTooLarge = newError("image_too_large", http.StatusUnprocessableEntity, "image too large", ErrImage)
+	ErrEncode        = newError("encode_failed", http.StatusUnprocessableEntity, "can not encode image", ErrImage)
+
+	ErrRender    = newError("render_failed", http.StatusInternalServerError, "render failed", nil)
+	ErrBlocked   = newError("blocked", http.StatusForbidden, "url is blocked", nil)
+	ErrQueueFull = newError("queue_full", http.StatusServiceUnavailable, "render queue is full", nil)
+	ErrWorker    = newError("worker_failed", http.StatusInternalServerError, "renderer worker failed", nil)
+
+	errTimeout = newError("timeout", http.StatusRequestTimeout, "timeout", nil)
+
+	errParams   = newError("invalid_params", http.StatusBadRequest, "invalid params", nil)
+	errNotFound = newError("not_found", http.StatusNotFound, "not found", nil)
+	errMethod   = newError("method_not_allowed", http.StatusMethodNotAllowed, "method not allowed", nil)
+	errConflict = newError("conflict", http.StatusConflict, "conflict", nil)
+	errTemplate = newError("template_failed", http.StatusUnprocessableEntity, "template failed", nil)
+)
+
+// retryAfter is Retry-After seconds sent when queue is full
+const retryAfter = 5
+
+// errorBody represents json error response
+type errorBody struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// Error returns error message
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Unwrap returns parent error
+func (e *Error) Unwrap() error {
+	if e.parent == nil {
+		return nil
+	}
+
+	return e.parent
+}
+
+// statusError returns target error for HTTP status code
+func statusError(status int) error {
+	if status >= 500 {
+		return fmt.Errorf("%w: status %d", ErrTarget5xx, status)
+	}
+
+	return fmt.Errorf("%w: status %d", ErrTarget4xx, status)
+}
+
+// contextError returns errTimeout if ctx deadline is exceeded, or ctx error
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTimeout
+	}
+
+	return ctx.Err()
+}
+
+// errorStatus returns HTTP status code for render error
+func errorStatus(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// errorCode returns code of render error
+func errorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return "internal_error"
+}
+
+// codeError returns render error for error code and message received over pipe
+func codeError(code, msg string) error {
+	e, ok := errorCodes[code]
+	if !ok {
+		return errors.New(msg)
+	}
+
+	if msg == e.Error() {
+		return e
+	}
+
+	return fmt.Errorf("%w: %s", e, strings.TrimPrefix(msg, e.Error()+": "))
+}
+
+// writeError writes render error as json with status code
+func writeError(w http.ResponseWriter, err error) {
+	code := errorStatus(err)
+	if code == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+
+	writeJSON(w, code, errorBody{errorCode(err), code, err.Error()})
+}
+
+// writeResultError writes render result error, with target status header if target page was loaded
+func writeResultError(w http.ResponseWriter, res Result) {
+	if res.Meta.Status != 0 {
+		w.Header().Set(TargetStatusHeader, strconv.Itoa(res.Meta.Status))
+	}
+
+	writeError(w, res.Err)
+}
@@ -0,0 +1,252 @@
+package url2img
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lookup cache
+const (
+	lookupTimeout = 2 * time.Second
+	lookupTTL     = time.Minute
+	maxLookups    = 1024
+)
+
+// privateNets are loopback, link-local, private (RFC1918), shared and unspecified address ranges
+var privateNets = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// Filter represents URL filter with host and CIDR allow/deny lists, private addresses are blocked unless allowed
+type Filter struct {
+	allowHosts []string
+	allowNets  []*net.IPNet
+	denyHosts  []string
+	denyNets   []*net.IPNet
+
+	privateNets  []*net.IPNet
+	allowPrivate bool
+
+	// lookups caches resolved addresses, so pages with many resources do not resolve same host again
+	mu      sync.Mutex
+	lookups map[string]lookupEntry
+}
+
+// lookupEntry represents cached lookup result
+type lookupEntry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+// NewFilter returns new filter, allow and deny are comma separated lists of hosts and CIDRs
+func NewFilter(allow, deny string, allowPrivate bool) (*Filter, error) {
+	f := &Filter{allowPrivate: allowPrivate, lookups: make(map[string]lookupEntry)}
+
+	var err error
+	f.allowHosts, f.allowNets, err = parseList(allow)
+	if err != nil {
+		return nil, err
+	}
+
+	f.denyHosts, f.denyNets, err = parseList(deny)
+	if err != nil {
+		return nil, err
+	}
+
+	_, f.privateNets, err = parseList(strings.Join(privateNets, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Check checks if url is allowed, only http(s) urls are checked, other network schemes are denied.
+// If resolve is false, e.g. when target is reached through proxy or request is checked in main loop,
+// host is not resolved and only host names and literal addresses are matched.
+func (f *Filter) Check(rawurl string, resolve bool) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, err.Error())
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return f.CheckHost(u.Hostname(), resolve)
+	case "data", "about", "blob":
+		return nil
+	}
+
+	return fmt.Errorf("%w: scheme %s is not allowed", ErrBlocked, u.Scheme)
+}
+
+// CheckHost checks if host, and addresses it resolves to, are allowed, returns ErrBlocked or ErrDNS if host can not be resolved.
+// Addresses are resolved again when connecting, so this does not protect against DNS rebinding.
+func (f *Filter) CheckHost(host string, resolve bool) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return fmt.Errorf("%w: empty host", ErrBlocked)
+	}
+
+	if matchHost(host, f.denyHosts) {
+		return fmt.Errorf("%w: host %s is denied", ErrBlocked, host)
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		ips = []net.IP{ip}
+	} else if resolve {
+		var err error
+		ips, err = f.lookup(host)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrDNS, err.Error())
+		}
+	} else if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		ips = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+
+	if !resolve && len(ips) == 0 {
+		// host name is resolved by proxy
+		if (len(f.allowHosts) > 0 || len(f.allowNets) > 0) && !matchHost(host, f.allowHosts) {
+			return fmt.Errorf("%w: host %s is not allowed", ErrBlocked, host)
+		}
+
+		return nil
+	}
+
+	return f.checkIPs(host, ips)
+}
+
+// CheckAddr checks if address host resolved to is allowed, it is used to check address that is actually dialed
+func (f *Filter) CheckAddr(host string, ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("%w: invalid address", ErrBlocked)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchHost(host, f.denyHosts) {
+		return fmt.Errorf("%w: host %s is denied", ErrBlocked, host)
+	}
+
+	return f.checkIPs(host, []net.IP{ip})
+}
+
+// checkIPs checks if addresses of host are allowed
+func (f *Filter) checkIPs(host string, ips []net.IP) error {
+	for _, ip := range ips {
+		if matchIP(ip, f.denyNets) {
+			return fmt.Errorf("%w: address %s is denied", ErrBlocked, ip)
+		}
+	}
+
+	hostAllowed := matchHost(host, f.allowHosts)
+	listed := len(f.allowHosts) > 0 || len(f.allowNets) > 0
+
+	for _, ip := range ips {
+		allowed := hostAllowed || matchIP(ip, f.allowNets)
+
+		if listed && !allowed {
+			return fmt.Errorf("%w: host %s is not allowed", ErrBlocked, host)
+		}
+
+		if !allowed && !f.allowPrivate && matchIP(ip, f.privateNets) {
+			return fmt.Errorf("%w: private address %s is not allowed", ErrBlocked, ip)
+		}
+	}
+
+	return nil
+}
+
+// parseList parses comma separated list of hosts and CIDRs
+func parseList(list string) (hosts []string, nets []*net.IPNet, err error) {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		if strings.Contains(item, "/") {
+			var n *net.IPNet
+			_, n, err = net.ParseCIDR(item)
+			if err != nil {
+				return
+			}
+
+			nets = append(nets, n)
+		} else if ip := net.ParseIP(item); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else {
+			hosts = append(hosts, strings.TrimSuffix(item, "."))
+		}
+	}
+
+	return
+}
+
+// lookup returns host addresses, results are cached for lookupTTL
+func (f *Filter) lookup(host string) ([]net.IP, error) {
+	f.mu.Lock()
+	e, ok := f.lookups[host]
+	f.mu.Unlock()
+
+	if ok && time.Now().Before(e.expires) {
+		return e.ips, e.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+
+	f.mu.Lock()
+	if len(f.lookups) >= maxLookups {
+		f.lookups = make(map[string]lookupEntry)
+	}
+	f.lookups[host] = lookupEntry{ips, err, time.Now().Add(lookupTTL)}
+	f.mu.Unlock()
+
+	return ips, err
+}
+
+// matchHost checks if host is equal to, or subdomain of, one of hosts
+func matchHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchIP checks if ip is in one of nets
+func matchIP(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
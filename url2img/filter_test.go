@@ -0,0 +1,141 @@
+package url2img
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	hosts, nets, err := parseList(" Example.com., 10.0.0.0/8,192.0.2.1, ::1 ,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hosts) != 1 || hosts[0] != "example.com" {
+		t.Errorf("hosts: got %v", hosts)
+	}
+
+	if len(nets) != 3 {
+		t.Fatalf("nets: got %v", nets)
+	}
+
+	if nets[1].String() != "192.0.2.1/32" || nets[2].String() != "::1/128" {
+		t.Errorf("nets: got %v", nets)
+	}
+
+	if _, _, err := parseList("10.0.0.0/33"); err == nil {
+		t.Error("invalid CIDR: expected error")
+	}
+}
+
+func TestFilterCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		allow        string
+		deny         string
+		allowPrivate bool
+		url          string
+		ok           bool
+	}{
+		{"public address", "", "", false, "http://93.184.216.34/", true},
+		{"public host", "", "", false, "https://example.com/", true},
+		{"data url", "", "", false, "data:text/html,test", true},
+		{"file scheme", "", "", false, "file:///etc/passwd", false},
+		{"ftp scheme", "", "", false, "ftp://example.com/", false},
+
+		{"loopback", "", "", false, "http://127.0.0.1/", false},
+		{"private 10", "", "", false, "http://10.1.2.3/", false},
+		{"private 172", "", "", false, "http://172.16.0.1:8080/", false},
+		{"private 192", "", "", false, "http://192.168.1.1/", false},
+		{"link-local", "", "", false, "http://169.254.169.254/latest/meta-data/", false},
+		{"unspecified", "", "", false, "http://0.0.0.0/", false},
+		{"allow private", "", "", true, "http://10.1.2.3/", true},
+
+		{"bracketed ipv6 loopback", "", "", false, "http://[::1]:8080/", false},
+		{"bracketed ipv6 unique local", "", "", false, "http://[fd00::1]/", false},
+		{"bracketed ipv6 public", "", "", false, "http://[2606:2800:220:1::1]/", true},
+		{"ipv4-mapped loopback", "", "", false, "http://[::ffff:127.0.0.1]/", false},
+		{"ipv4-mapped private", "", "", false, "http://[::ffff:10.0.0.1]/", false},
+		{"ipv4-mapped denied", "", "198.51.100.0/24", false, "http://[::ffff:198.51.100.7]/", false},
+
+		{"localhost", "", "", false, "http://localhost:55888/", false},
+		{"localhost uppercase", "", "", false, "http://LOCALHOST/", false},
+		{"localhost subdomain", "", "", false, "http://app.localhost/", false},
+		{"localhost trailing dot", "", "", false, "http://localhost./", false},
+
+		{"denied host", "", "example.org", false, "http://example.org/", false},
+		{"denied subdomain", "", "example.org", false, "http://www.example.org/", false},
+		{"denied suffix is not subdomain", "", "example.org", false, "http://badexample.org/", true},
+		{"denied cidr", "", "203.0.113.0/24", false, "http://203.0.113.10/", false},
+		{"deny precedes allow host", "example.com", "www.example.com", false, "http://www.example.com/", false},
+		{"deny precedes allow cidr", "10.0.0.0/8", "10.0.0.1", true, "http://10.0.0.1/", false},
+
+		{"allowed host", "example.com", "", false, "http://example.com/", true},
+		{"allowed subdomain", "example.com", "", false, "http://www.example.com/", true},
+		{"not allowed host", "example.com", "", false, "http://example.net/", false},
+		{"not allowed suffix", "example.com", "", false, "http://notexample.com/", false},
+		{"allowed cidr", "203.0.113.0/24", "", false, "http://203.0.113.10/", true},
+		{"not allowed address", "203.0.113.0/24", "", false, "http://198.51.100.1/", false},
+		{"allowed private cidr", "10.0.0.0/8", "", false, "http://10.1.2.3/", true},
+		{"allowed localhost", "localhost", "", false, "http://localhost/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.allow, tt.deny, tt.allowPrivate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// hosts are not resolved, as behind proxy
+			err = f.Check(tt.url, false)
+			if tt.ok && err != nil {
+				t.Errorf("%s: expected allowed, got %v", tt.url, err)
+			} else if !tt.ok && err == nil {
+				t.Errorf("%s: expected blocked", tt.url)
+			}
+		})
+	}
+}
+
+func TestFilterCheckHost(t *testing.T) {
+	f, err := NewFilter("", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.CheckHost("", false); err == nil {
+		t.Error("empty host: expected error")
+	}
+
+	// literal addresses and localhost are matched without lookup
+	for _, host := range []string{"127.0.0.1", "[::1]", "::ffff:192.168.0.1", "localhost"} {
+		if err := f.CheckHost(host, true); !errors.Is(err, ErrBlocked) {
+			t.Errorf("%s: expected blocked, got %v", host, err)
+		}
+	}
+
+	// dialed address is checked with host it was resolved from
+	allowed, err := NewFilter("intranet.example.com", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := allowed.CheckAddr("intranet.example.com", net.ParseIP("10.0.0.1")); err != nil {
+		t.Errorf("allowed host: got %v", err)
+	}
+
+	if err := f.CheckAddr("example.com", net.ParseIP("10.0.0.1")); !errors.Is(err, ErrBlocked) {
+		t.Errorf("rebound host: expected blocked, got %v", err)
+	}
+
+	if err := f.CheckAddr("example.com", nil); !errors.Is(err, ErrBlocked) {
+		t.Errorf("invalid address: expected blocked, got %v", err)
+	}
+
+	// lookup failure is not reported as blocked host
+	if err := f.CheckHost("nonexistent.invalid", true); !errors.Is(err, ErrDNS) {
+		t.Errorf("expected dns failure, got %v", err)
+	}
+}
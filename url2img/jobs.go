@@ -0,0 +1,264 @@
+package url2img
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRendering = "rendering"
+	JobDone      = "done"
+	JobFailed    = "failed"
+)
+
+// Job represents asynchronous capture job
+type Job struct {
+	Id           string     `json:"id"`
+	Url          string     `json:"url"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	Code         string     `json:"code,omitempty"`
+	TargetStatus int        `json:"target_status,omitempty"`
+	Created      time.Time  `json:"created"`
+	Finished     *time.Time `json:"finished,omitempty"`
+
+	params Params
+	res    Result
+}
+
+// Jobs represents job store
+type Jobs struct {
+	sync.RWMutex
+
+	m   map[string]*Job
+	ttl time.Duration
+	max int
+}
+
+// NewJobs returns new job store, finished jobs are removed after ttl, or when there are more than max jobs
+func NewJobs(ttl time.Duration, max int) *Jobs {
+	j := &Jobs{m: make(map[string]*Job), ttl: ttl, max: max}
+	go j.sweep()
+
+	return j
+}
+
+// Add adds new queued job for params
+func (j *Jobs) Add(p Params) Job {
+	j.Lock()
+	defer j.Unlock()
+
+	j.expire()
+	if j.max > 0 && len(j.m) >= j.max {
+		j.evict()
+	}
+
+	job := &Job{Id: p.Id, Url: p.Url, Status: JobQueued, Created: time.Now(), params: p}
+	j.m[p.Id] = job
+
+	return *job
+}
+
+// Get returns job by id
+func (j *Jobs) Get(id string) (Job, bool) {
+	j.RLock()
+	defer j.RUnlock()
+
+	job, ok := j.m[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return *job, true
+}
+
+// SetStatus sets job status
+func (j *Jobs) SetStatus(id, status string) {
+	j.Lock()
+	defer j.Unlock()
+
+	if job, ok := j.m[id]; ok {
+		job.Status = status
+	}
+}
+
+// Finish stores job result
+func (j *Jobs) Finish(id string, res Result) {
+	j.Lock()
+	defer j.Unlock()
+
+	job, ok := j.m[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	job.Finished = &now
+
+	job.res = res
+	job.TargetStatus = res.Meta.Status
+
+	if res.Err != nil {
+		job.Status = JobFailed
+		job.Error = res.Err.Error()
+		job.Code = errorCode(res.Err)
+		return
+	}
+
+	job.Status = JobDone
+}
+
+// expire removes finished jobs older than ttl, store must be locked
+func (j *Jobs) expire() {
+	for id, job := range j.m {
+		if job.Finished != nil && time.Since(*job.Finished) > j.ttl {
+			delete(j.m, id)
+		}
+	}
+}
+
+// evict removes oldest finished job, store must be locked
+func (j *Jobs) evict() {
+	var oldest *Job
+	for _, job := range j.m {
+		if job.Finished != nil && (oldest == nil || job.Finished.Before(*oldest.Finished)) {
+			oldest = job
+		}
+	}
+
+	if oldest != nil {
+		delete(j.m, oldest.Id)
+	}
+}
+
+// sweep periodically removes expired jobs, so results are freed even when no new jobs are added
+func (j *Jobs) sweep() {
+	interval := j.ttl
+	if interval > time.Minute || interval <= 0 {
+		interval = time.Minute
+	}
+
+	for range time.Tick(interval) {
+		j.Lock()
+		j.expire()
+		j.Unlock()
+	}
+}
+
+// serveJobs handles /jobs requests
+func (s *Server) serveJobs(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+
+	if path == "" {
+		if r.Method != "POST" {
+			writeError(w, fmt.Errorf("%w: %s", errMethod, r.Method))
+			return
+		}
+
+		s.createJob(w, r)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, fmt.Errorf("%w: %s", errMethod, r.Method))
+		return
+	}
+
+	parts := strings.Split(path, "/")
+
+	job, ok := s.jobs.Get(parts[0])
+	if !ok || len(parts) > 2 || (len(parts) == 2 && parts[1] != "result") {
+		writeError(w, fmt.Errorf("%w: %s", errNotFound, r.URL.Path))
+		return
+	}
+
+	if len(parts) == 1 {
+		writeJSON(w, http.StatusOK, job)
+		return
+	}
+
+	switch job.Status {
+	case JobDone:
+		s.write(w, job.params, job.res)
+	case JobFailed:
+		writeResultError(w, job.res)
+	default:
+		writeError(w, fmt.Errorf("%w: job is %s", errConflict, job.Status))
+	}
+}
+
+// createJob queues new job and returns it immediately
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request) {
+	p := NewParams()
+
+	err := p.BodyValues(r)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %s", errParams, err.Error()))
+		return
+	}
+
+	if err := s.checkCallback(p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.prepare(&p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// job takes place in queue on submit, so it is rejected when queue is full
+	place, err := s.queue.Enter()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	job := s.jobs.Add(p)
+
+	go func() {
+		s.queue.Wait(context.Background(), place)
+		defer s.queue.Release()
+
+		s.jobs.SetStatus(p.Id, JobRendering)
+
+		// job timeout starts when job leaves the queue
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.JobTimeout)*time.Second)
+		res := s.Renderer.Render(ctx, p)
+		cancel()
+
+		if res.Err == errTimeout {
+			res.Err = fmt.Errorf("%w after %d seconds", errTimeout, s.JobTimeout)
+		}
+
+		if p.Callback != "" {
+			go s.callback(p, res.Data, res.Err)
+		}
+
+		s.jobs.Finish(p.Id, res)
+	}()
+
+	w.Header().Set("Location", "/jobs/"+job.Id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// writeJSON writes v as json response with status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		msg := fmt.Sprintf("500 Internal Server Error (%s)", err.Error())
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
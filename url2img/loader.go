@@ -1,7 +1,14 @@
 package url2img
 
 import (
-	"encoding/hex"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -11,6 +18,7 @@ import (
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/gui"
 	"github.com/therecipe/qt/network"
+	"github.com/therecipe/qt/printsupport"
 	"github.com/therecipe/qt/webkit"
 	"github.com/therecipe/qt/widgets"
 )
@@ -21,16 +29,94 @@ import (
 type Object struct {
 	core.QObject
 
-	_ func(data string)     `signal:"load"`
-	_ func(id, data string) `signal:"loadFinished"`
+	_ func(data string) `signal:"load"`
+	_ func(id string)   `signal:"ping"`
+	_ func(id string)   `signal:"cancel"`
 }
 
+// pageSizes maps pdf page size names to Qt page sizes
+var pageSizes = map[string]gui.QPageSize__PageSizeId{
+	"A3":     gui.QPageSize__A3,
+	"A4":     gui.QPageSize__A4,
+	"A5":     gui.QPageSize__A5,
+	"Letter": gui.QPageSize__Letter,
+	"Legal":  gui.QPageSize__Legal,
+}
+
+// waitInterval is readiness condition polling interval (milliseconds)
+const waitInterval = 50
+
+// maxConsole is maximum number of console errors kept for page
+const maxConsole = 100
+
+// consolePrefix marks console messages that are reported by consoleScript
+const consolePrefix = "__url2img_error__:"
+
+// consoleScript wraps console.error and reports uncaught errors as prefixed console messages, other messages are ignored
+const consoleScript = `(function() {
+	var report = console.log;
+	var error = console.error;
+	console.error = function() {
+		report.call(console, "` + consolePrefix + `" + Array.prototype.slice.call(arguments).join(" "));
+		if (error) {
+			error.apply(console, arguments);
+		}
+	};
+	window.addEventListener("error", function(e) {
+		report.call(console, "` + consolePrefix + `" + e.message + " (" + e.filename + ":" + e.lineno + ")");
+	});
+})();`
+
+// jsTemplate evaluates script in global scope, if it returns promise-like object done flag is set when it settles
+const jsTemplate = `(function() {
+	window.__url2imgDone = false;
+	window.__url2imgError = false;
+	var done = function() { window.__url2imgDone = true; };
+	var fail = function() { window.__url2imgError = true; done(); };
+	try {
+		var r = (0, eval)(%s);
+		if (r && typeof r.then === "function") {
+			r.then(done, fail);
+		} else {
+			done();
+		}
+	} catch (e) {
+		fail();
+	}
+})();`
+
+// jsUrlTemplate loads script from url, done flag is set when it is loaded
+const jsUrlTemplate = `(function() {
+	window.__url2imgDone = false;
+	window.__url2imgError = false;
+	var s = document.createElement("script");
+	s.src = %s;
+	s.onload = function() { window.__url2imgDone = true; };
+	s.onerror = function() { window.__url2imgError = true; window.__url2imgDone = true; };
+	(document.head || document.documentElement).appendChild(s);
+})();`
+
 // Loader represents image loader
 type Loader struct {
 	*Object
 	*widgets.QWidget
 	app *widgets.QApplication
-	Map sync.Map
+
+	// Filter checks every request issued by pages, if nil all requests are allowed
+	Filter *Filter
+
+	// pages maps ids to views of pages that are rendering, it is used only in main loop
+	pages map[string]*webkit.QWebView
+
+	// results maps ids to renders waiting for page results
+	mu      sync.Mutex
+	results map[string]*request
+}
+
+// request represents render waiting for page result
+type request struct {
+	c      chan Result
+	loaded bool
 }
 
 // NewLoader returns new loader
@@ -45,9 +131,13 @@ func NewLoader() *Loader {
 	widget.SetAttribute(core.Qt__WA_DontShowOnScreen, true)
 	widget.Show()
 
-	var sm sync.Map
-
-	l := &Loader{NewObject(nil), widget, app, sm}
+	l := &Loader{
+		Object:  NewObject(nil),
+		QWidget: widget,
+		app:     app,
+		pages:   make(map[string]*webkit.QWebView),
+		results: make(map[string]*request),
+	}
 
 	l.ConnectLoad(func(data string) {
 		params := NewParams()
@@ -57,13 +147,85 @@ func NewLoader() *Loader {
 		}
 	})
 
-	l.ConnectLoadFinished(func(id, data string) {
-		l.Map.Store(id, data)
+	l.ConnectCancel(func(id string) {
+		view, ok := l.pages[id]
+		if !ok {
+			return
+		}
+		delete(l.pages, id)
+
+		view.Stop()
+		view.DeleteLater()
 	})
 
 	return l
 }
 
+// Render loads page and waits for result, page is stopped and its result discarded when ctx is done
+func (l *Loader) Render(ctx context.Context, p Params) Result {
+	d, err := p.Marshal()
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	req := &request{c: make(chan Result, 1)}
+
+	l.mu.Lock()
+	l.results[p.Id] = req
+	l.mu.Unlock()
+
+	l.Load(d)
+
+	select {
+	case r := <-req.c:
+		return r
+	case <-ctx.Done():
+		l.mu.Lock()
+		delete(l.results, p.Id)
+		loaded := req.loaded
+		l.mu.Unlock()
+
+		l.Cancel(p.Id)
+
+		err := contextError(ctx)
+		if err == errTimeout && !loaded {
+			err = ErrLoadTimeout
+		}
+
+		return Result{Err: err}
+	}
+}
+
+// setLoaded marks that page of waiting render is loaded
+func (l *Loader) setLoaded(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if req, ok := l.results[id]; ok {
+		req.loaded = true
+	}
+}
+
+// finish sends page result to waiting render and deletes page view, it is called in main loop
+func (l *Loader) finish(id string, r Result) {
+	view, ok := l.pages[id]
+	if !ok {
+		// render was canceled
+		return
+	}
+	delete(l.pages, id)
+	view.DeleteLater()
+
+	l.mu.Lock()
+	req, ok := l.results[id]
+	delete(l.results, id)
+	l.mu.Unlock()
+
+	if ok {
+		req.c <- r
+	}
+}
+
 // LoadPage loads page
 func (l *Loader) LoadPage(p Params) {
 	view := webkit.NewQWebView(l.QWidget_PTR())
@@ -71,12 +233,13 @@ func (l *Loader) LoadPage(p Params) {
 	view.Resize2(p.Width, p.Width)
 
 	page := webkit.NewQWebPage(view.QWidget_PTR())
+	l.pages[p.Id] = view
 
-	// ignore ssl certificate errors
-	networkAccessManager := network.NewQNetworkAccessManager(page)
-	networkAccessManager.ConnectSslErrors(func(reply *network.QNetworkReply, errors []*network.QSslError) {
-		reply.IgnoreSslErrors()
-	})
+	// main url is normalized by Qt, as urls of requests and replies it is compared to
+	main := core.NewQUrl3(p.PageUrl(), core.QUrl__TolerantMode).ToString(core.QUrl__None)
+
+	act := &activity{last: time.Now(), start: time.Now(), main: main, console: make([]string, 0)}
+	networkAccessManager := l.newNetworkAccessManager(p, page, act)
 	page.SetNetworkAccessManager(networkAccessManager)
 
 	view.SetPage(page)
@@ -96,83 +259,418 @@ func (l *Loader) LoadPage(p Params) {
 	l.setAttributes(page.Settings())
 	l.setPath(page.Settings(), os.TempDir())
 
-	page.ConnectLoadFinished(func(bool) {
-		if p.Delay > 0 && !p.Full {
-			time.Sleep(time.Duration(p.Delay) * time.Millisecond)
+	if p.Css != "" || len(p.HideSelectors) > 0 {
+		page.Settings().SetUserStyleSheetUrl(l.styleSheet(p))
+	}
+
+	// QtWebKit reports console messages without level, so only errors reported by consoleScript are kept
+	page.MainFrame().ConnectJavaScriptWindowObjectCleared(func() {
+		page.MainFrame().EvaluateJavaScript(consoleScript)
+	})
+
+	page.ConnectJavaScriptConsoleMessage(func(message string, lineNumber int, sourceID string) {
+		if strings.HasPrefix(message, consolePrefix) && len(act.console) < maxConsole {
+			act.console = append(act.console, strings.TrimPrefix(message, consolePrefix))
 		}
+	})
 
-		if p.Full {
-			js := `var d=document;
-				Math.max(Math.max(d.body.scrollHeight, d.documentElement.scrollHeight),
-				Math.max(d.body.offsetHeight, d.documentElement.offsetHeight),
-				Math.max(d.body.clientHeight, d.documentElement.clientHeight));`
-			tmp := true
-			p.Height = page.MainFrame().EvaluateJavaScript(js).ToInt(&tmp)
+	loaded := false
+	page.ConnectLoadFinished(func(success bool) {
+		if _, ok := l.pages[p.Id]; !ok || loaded {
+			// page was canceled, or is already loaded
+			return
+		}
+		loaded = true
+		act.loaded = time.Now()
+		l.setLoaded(p.Id)
+
+		if act.err == nil && act.status >= 400 && p.failOnStatus(act.status) {
+			act.err = statusError(act.status)
+		}
 
-			if p.Height == 0 {
-				p.Height = DefHeight
-			} else if p.Height > 32768 {
-				p.Height = 32768
+		finish := func(err error) {
+			if err != nil {
+				l.finish(p.Id, Result{Err: err, Meta: l.meta(page.MainFrame(), act)})
+				return
 			}
 
-			page.SetViewportSize(core.NewQSize2(p.Width, p.Height))
-			view.Resize2(p.Width, p.Height)
+			l.capture(page, view, p, act)
+		}
 
-			page.MainFrame().EvaluateJavaScript(`window.scrollTo(0, ` + strconv.Itoa(p.Height) + `);`)
+		if act.err != nil {
+			finish(act.err)
+			return
+		}
 
-			if p.Delay > 0 {
-				time.Sleep(time.Duration(p.Delay) * time.Millisecond)
+		l.wait(page, p, act, func(err error) {
+			if err != nil {
+				finish(err)
+				return
 			}
+
+			l.script(page, p, finish)
+		})
+	})
+
+	view.Show()
+	if p.Html != "" {
+		page.MainFrame().SetHtml(p.Html, core.NewQUrl3(p.BaseUrl, core.QUrl__TolerantMode))
+		return
+	}
+
+	view.Load(core.NewQUrl3(p.Url, core.QUrl__TolerantMode))
+}
+
+// capture renders page and sends image data
+func (l *Loader) capture(page *webkit.QWebPage, view *webkit.QWebView, p Params, act *activity) {
+	if p.Delay > 0 && !p.Full {
+		time.Sleep(time.Duration(p.Delay) * time.Millisecond)
+	}
+
+	if p.Full {
+		js := `var d=document;
+			Math.max(Math.max(d.body.scrollHeight, d.documentElement.scrollHeight),
+			Math.max(d.body.offsetHeight, d.documentElement.offsetHeight),
+			Math.max(d.body.clientHeight, d.documentElement.clientHeight));`
+		tmp := true
+		p.Height = page.MainFrame().EvaluateJavaScript(js).ToInt(&tmp)
+
+		if p.Height == 0 {
+			p.Height = DefHeight
+		} else if p.Height > 32768 {
+			p.Height = 32768
 		}
 
-		image := gui.NewQImage3(p.Width, p.Height, gui.QImage__Format_RGB888)
-		if image.IsNull() {
-			l.LoadFinished(p.Id, "ErrIsNull")
-			view.DeleteLater()
-			return
+		page.SetViewportSize(core.NewQSize2(p.Width, p.Height))
+		view.Resize2(p.Width, p.Height)
+
+		page.MainFrame().EvaluateJavaScript(`window.scrollTo(0, ` + strconv.Itoa(p.Height) + `);`)
+
+		if p.Delay > 0 {
+			time.Sleep(time.Duration(p.Delay) * time.Millisecond)
 		}
+	}
+
+	meta := l.meta(page.MainFrame(), act)
 
-		painter := gui.NewQPainter()
-		painter.Begin(gui.NewQPaintDeviceFromPointer(image.Pointer()))
-		if !painter.IsActive() {
-			l.LoadFinished(p.Id, "ErrIsActive")
-			view.DeleteLater()
+	if p.Format == "pdf" {
+		l.print(page.MainFrame(), p, meta)
+		return
+	}
+
+	x, y := 0, 0
+	if p.Selector != "" {
+		var found bool
+		x, y, found = l.element(page, view, &p)
+		if !found {
+			l.finish(p.Id, Result{Err: ErrSelector, Meta: meta})
 			return
 		}
+	}
+
+	image := gui.NewQImage3(p.Width, p.Height, gui.QImage__Format_RGB888)
+	if image.IsNull() {
+		l.finish(p.Id, Result{Err: fmt.Errorf("%w: %dx%d", ErrImageTooLarge, p.Width, p.Height), Meta: meta})
+		return
+	}
+
+	painter := gui.NewQPainter()
+	painter.Begin(gui.NewQPaintDeviceFromPointer(image.Pointer()))
+	if !painter.IsActive() {
+		l.finish(p.Id, Result{Err: fmt.Errorf("%w: can not paint image", ErrRender), Meta: meta})
+		return
+	}
+
+	painter.SetRenderHint(gui.QPainter__Antialiasing, true)
+	painter.SetRenderHint(gui.QPainter__TextAntialiasing, true)
+	painter.SetRenderHint(gui.QPainter__HighQualityAntialiasing, true)
+	painter.SetRenderHint(gui.QPainter__SmoothPixmapTransform, true)
+	painter.Translate3(float64(-x), float64(-y))
+	page.MainFrame().Render(painter, gui.NewQRegion2(x, y, p.Width, p.Height, gui.QRegion__Rectangle))
+	painter.End()
+
+	image = l.thumbnail(image, p)
+
+	buff := core.NewQBuffer(view)
+	buff.Open(core.QIODevice__ReadWrite)
+	if !buff.IsWritable() {
+		l.finish(p.Id, Result{Err: fmt.Errorf("%w: can not open image buffer", ErrRender), Meta: meta})
+		return
+	}
+
+	format, quality := strings.ToUpper(p.Format), p.Quality
+	if p.Format == "gif" {
+		// Qt can only read gif, it is encoded from png
+		format = "PNG"
+	} else if p.Format == "webp" && p.Lossless {
+		// webp plugin encodes lossless with quality 100
+		quality = 100
+	}
+
+	var err error
+	ok := image.Save2(buff, format, quality)
+	data := []byte(buff.Data().ConstData())
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrEncode, p.Format)
+	} else if p.Format == "gif" {
+		data, err = toGif(data)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", ErrEncode, err.Error())
+		}
+	}
 
-		painter.SetRenderHint(gui.QPainter__Antialiasing, true)
-		painter.SetRenderHint(gui.QPainter__TextAntialiasing, true)
-		painter.SetRenderHint(gui.QPainter__HighQualityAntialiasing, true)
-		painter.SetRenderHint(gui.QPainter__SmoothPixmapTransform, true)
-		page.MainFrame().Render(painter, gui.NewQRegion())
-		painter.End()
+	image.DestroyQImage()
+
+	buff.Close()
+	buff.DeleteLater()
+
+	if err != nil {
+		l.finish(p.Id, Result{Err: err, Meta: meta})
+		return
+	}
+
+	l.finish(p.Id, Result{Data: data, Meta: meta})
+}
 
-		buff := core.NewQBuffer(view)
-		buff.Open(core.QIODevice__ReadWrite)
-		if !buff.IsWritable() {
-			l.LoadFinished(p.Id, "ErrIsWritable")
-			view.DeleteLater()
+// meta returns main frame metadata
+func (l *Loader) meta(frame *webkit.QWebFrame, act *activity) Meta {
+	return Meta{
+		Url:           frame.Url().ToString(core.QUrl__None),
+		Title:         frame.Title(),
+		Status:        act.status,
+		ContentHeight: frame.ContentsSize().Height(),
+		LoadTime:      int64(act.loaded.Sub(act.start) / time.Millisecond),
+		Resources:     act.resources,
+		ConsoleErrors: act.console,
+	}
+}
+
+// wait waits until readiness condition is met or wait timeout expires
+func (l *Loader) wait(page *webkit.QWebPage, p Params, act *activity, done func(err error)) {
+	if p.WaitFor == "" {
+		done(nil)
+		return
+	}
+
+	l.poll(page, time.Duration(p.WaitTimeout)*time.Millisecond, func() bool {
+		switch p.WaitFor {
+		case "selector":
+			return !page.MainFrame().FindFirstElement(p.WaitSelector).IsNull()
+		case "idle":
+			return act.pending == 0 && time.Since(act.last) >= time.Duration(p.WaitIdle)*time.Millisecond
+		case "js":
+			return page.MainFrame().EvaluateJavaScript(p.WaitJs).ToBool()
+		}
+		return true
+	}, func(ok bool) {
+		if !ok {
+			done(ErrWaitTimeout)
 			return
 		}
+		done(nil)
+	})
+}
+
+// script runs js_url and js scripts in main frame, waits for returned promise to settle
+func (l *Loader) script(page *webkit.QWebPage, p Params, done func(err error)) {
+	scripts := make([]string, 0)
+	if p.JsUrl != "" {
+		scripts = append(scripts, fmt.Sprintf(jsUrlTemplate, jsString(p.JsUrl)))
+	}
+	if p.Js != "" {
+		scripts = append(scripts, fmt.Sprintf(jsTemplate, jsString(p.Js)))
+	}
+
+	l.run(page, scripts, time.Duration(p.WaitTimeout)*time.Millisecond, done)
+}
+
+// run evaluates scripts in order, each must set done flag before timeout
+func (l *Loader) run(page *webkit.QWebPage, scripts []string, timeout time.Duration, done func(err error)) {
+	if len(scripts) == 0 {
+		done(nil)
+		return
+	}
 
-		ok := image.Save2(buff, strings.ToUpper(p.Format), p.Quality)
-		data := []byte(buff.Data().ConstData())
+	frame := page.MainFrame()
+	frame.EvaluateJavaScript(scripts[0])
+
+	l.poll(page, timeout, func() bool {
+		return frame.EvaluateJavaScript("window.__url2imgDone === true").ToBool()
+	}, func(ok bool) {
 		if !ok {
-			data = []byte("ErrSave2")
+			done(ErrWaitTimeout)
+			return
 		}
 
-		l.LoadFinished(p.Id, hex.EncodeToString(data))
-
-		image.DestroyQImage()
+		if frame.EvaluateJavaScript("window.__url2imgError === true").ToBool() {
+			done(ErrScript)
+			return
+		}
 
-		buff.Close()
-		buff.DeleteLater()
+		l.run(page, scripts[1:], timeout, done)
+	})
+}
 
-		view.DeleteLater()
+// poll checks condition every waitInterval until it is true or timeout expires
+func (l *Loader) poll(page *webkit.QWebPage, timeout time.Duration, cond func() bool, done func(ok bool)) {
+	if cond() {
+		done(true)
+		return
+	}
+
+	start := time.Now()
+
+	timer := core.NewQTimer(page)
+	timer.ConnectTimeout(func() {
+		ready := cond()
+		if ready || time.Since(start) > timeout {
+			timer.Stop()
+			timer.DeleteLater()
+			done(ready)
+		}
 	})
+	timer.Start(waitInterval)
+}
 
-	view.Show()
-	view.Load(core.NewQUrl3(p.Url, core.QUrl__TolerantMode))
+// element finds element by selector and returns its position, params width and height are set to element size
+func (l *Loader) element(page *webkit.QWebPage, view *webkit.QWebView, p *Params) (x, y int, ok bool) {
+	frame := page.MainFrame()
+
+	element := frame.FindFirstElement(p.Selector)
+	if element.IsNull() {
+		return
+	}
+
+	// whole document must be in viewport to render elements below the fold
+	width, height := frame.ContentsSize().Width(), frame.ContentsSize().Height()
+	if height > 32768 {
+		height = 32768
+	}
+
+	page.SetViewportSize(core.NewQSize2(width, height))
+	view.Resize2(width, height)
+	frame.SetScrollPosition(core.NewQPoint2(0, 0))
+
+	geometry := element.Geometry()
+	if geometry.Width() == 0 || geometry.Height() == 0 {
+		return
+	}
+
+	p.Width, p.Height = geometry.Width(), geometry.Height()
+	if p.Height > 32768 {
+		p.Height = 32768
+	}
+
+	return geometry.X(), geometry.Y(), true
+}
+
+// print prints frame to pdf, print media css is applied
+func (l *Loader) print(frame *webkit.QWebFrame, p Params, meta Meta) {
+	file, err := ioutil.TempFile("", Name)
+	if err != nil {
+		l.finish(p.Id, Result{Err: fmt.Errorf("%w: %s", ErrRender, err.Error()), Meta: meta})
+		return
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	orientation := gui.QPageLayout__Portrait
+	if p.Orientation == "landscape" {
+		orientation = gui.QPageLayout__Landscape
+	}
+
+	margins := core.NewQMarginsF2(p.Margin, p.Margin, p.Margin, p.Margin)
+	layout := gui.NewQPageLayout2(gui.NewQPageSize2(pageSizes[p.PageSize]), orientation, margins, gui.QPageLayout__Millimeter, core.NewQMarginsF())
+
+	printer := printsupport.NewQPrinter(printsupport.QPrinter__HighResolution)
+	printer.SetOutputFormat(printsupport.QPrinter__PdfFormat)
+	printer.SetOutputFileName(file.Name())
+	if !printer.SetPageLayout(layout) {
+		l.finish(p.Id, Result{Err: fmt.Errorf("%w: invalid page layout", ErrEncode), Meta: meta})
+		printer.DestroyQPrinter()
+		return
+	}
+
+	frame.Print(printer)
+	printer.DestroyQPrinter()
+
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil || len(data) == 0 {
+		l.finish(p.Id, Result{Err: fmt.Errorf("%w: pdf", ErrEncode), Meta: meta})
+		return
+	}
+
+	l.finish(p.Id, Result{Data: data, Meta: meta})
+}
+
+// thumbnail crops and scales image to thumbnail size
+func (l *Loader) thumbnail(image *gui.QImage, p Params) *gui.QImage {
+	if p.Crop != "" && p.Crop != "top" && p.Crop != "center" {
+		x, y, w, h, _ := parseRect(p.Crop)
+		image = l.replace(image, image.Copy2(x, y, w, h))
+	}
+
+	switch {
+	case p.ThumbWidth > 0 && p.ThumbHeight > 0:
+		if p.Crop != "top" && p.Crop != "center" {
+			image = l.replace(image, image.Scaled2(p.ThumbWidth, p.ThumbHeight, core.Qt__KeepAspectRatio, core.Qt__SmoothTransformation))
+			break
+		}
+
+		image = l.replace(image, image.Scaled2(p.ThumbWidth, p.ThumbHeight, core.Qt__KeepAspectRatioByExpanding, core.Qt__SmoothTransformation))
+
+		x, y := (image.Width()-p.ThumbWidth)/2, 0
+		if p.Crop == "center" {
+			y = (image.Height() - p.ThumbHeight) / 2
+		}
+
+		image = l.replace(image, image.Copy2(x, y, p.ThumbWidth, p.ThumbHeight))
+	case p.ThumbWidth > 0:
+		image = l.replace(image, image.ScaledToWidth(p.ThumbWidth, core.Qt__SmoothTransformation))
+	case p.ThumbHeight > 0:
+		image = l.replace(image, image.ScaledToHeight(p.ThumbHeight, core.Qt__SmoothTransformation))
+	}
+
+	return image
+}
+
+// replace destroys old image and returns new one
+func (l *Loader) replace(old, image *gui.QImage) *gui.QImage {
+	old.DestroyQImage()
+	return image
+}
+
+// jsString returns string as JavaScript string literal
+func jsString(str string) string {
+	data, _ := json.Marshal(str)
+	return string(data)
+}
+
+// toGif converts png data to static gif
+func toGif(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = gif.Encode(&buf, img, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// styleSheet returns user style sheet data url with custom css and hidden elements
+func (l *Loader) styleSheet(p Params) *core.QUrl {
+	css := p.Css
+	for _, selector := range p.HideSelectors {
+		css += "\n" + selector + " { display: none !important; }"
+	}
+
+	data := "data:text/css;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(css))
+	return core.NewQUrl3(data, core.QUrl__TolerantMode)
 }
 
 // setAttributes sets web page attributes
@@ -211,6 +709,15 @@ func (l *Loader) setPath(settings *webkit.QWebSettings, path string) {
 	settings.SetOfflineWebApplicationCachePath(path)
 }
 
+// AddCaCertificates adds PEM certificates from file to default CA certificates
+func (l *Loader) AddCaCertificates(path string) error {
+	if !network.QSslSocket_AddDefaultCaCertificates(path, network.QSsl__Pem, core.QRegExp__FixedString) {
+		return fmt.Errorf("no certificates in %s", path)
+	}
+
+	return nil
+}
+
 // Exec starts Qt main loop
 func (l *Loader) Exec() {
 	l.app.Exec()
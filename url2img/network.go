@@ -0,0 +1,242 @@
+package url2img
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/network"
+)
+
+// proxyPorts maps proxy schemes to default ports, QtNetwork can not connect to proxy with TLS so https proxy is not supported
+var proxyPorts = map[string]int{
+	"http":   80,
+	"socks5": 1080,
+}
+
+// activity represents page network activity
+type activity struct {
+	pending   int
+	last      time.Time
+	resources int
+
+	// page load start and finish time
+	start  time.Time
+	loaded time.Time
+
+	// main document url, its HTTP status and network error
+	main   string
+	status int
+	err    error
+
+	// console.error messages and uncaught script errors
+	console []string
+}
+
+// isMain checks if url is main document url
+func (a *activity) isMain(rawurl string) bool {
+	return sameUrl(rawurl, a.main)
+}
+
+// newNetworkAccessManager returns network access manager for params, network activity is tracked in act
+func (l *Loader) newNetworkAccessManager(p Params, parent core.QObject_ITF, act *activity) *network.QNetworkAccessManager {
+	networkAccessManager := network.NewQNetworkAccessManager(parent)
+
+	// ignore ssl certificate errors, unless tls is strict
+	networkAccessManager.ConnectSslErrors(func(reply *network.QNetworkReply, errors []*network.QSslError) {
+		if p.TLS != "strict" {
+			reply.IgnoreSslErrors()
+			return
+		}
+
+		if act.isMain(reply.Url().ToString(core.QUrl__None)) {
+			msgs := make([]string, 0, len(errors))
+			for _, e := range errors {
+				msgs = append(msgs, e.ErrorString())
+			}
+
+			act.err = fmt.Errorf("%w: %s", ErrTLS, strings.Join(msgs, "; "))
+		}
+	})
+
+	targetOrigin := origin(act.main)
+
+	networkAccessManager.ConnectCreateRequest(func(op network.QNetworkAccessManager__Operation, req *network.QNetworkRequest, data *core.QIODevice) *network.QNetworkReply {
+		act.pending++
+		act.last = time.Now()
+
+		// blocked request is replaced with unknown scheme request that fails immediately,
+		// hosts are not resolved here as lookup would block main loop, target url is resolved by server before render
+		if l.Filter != nil {
+			u := req.Url().ToString(core.QUrl__None)
+			if err := l.Filter.Check(u, false); err != nil {
+				if act.isMain(u) {
+					act.err = err
+				}
+
+				req = network.NewQNetworkRequest(core.NewQUrl3("blocked:", core.QUrl__TolerantMode))
+				return networkAccessManager.CreateRequestDefault(op, req, data)
+			}
+		}
+
+		if len(p.Headers) > 0 && (!p.HeadersOrigin || origin(req.Url().ToString(core.QUrl__None)) == targetOrigin) {
+			req = network.NewQNetworkRequest2(req)
+			for name, value := range p.Headers {
+				req.SetRawHeader(core.NewQByteArray2(name, len(name)), core.NewQByteArray2(value, len(value)))
+			}
+		}
+
+		return networkAccessManager.CreateRequestDefault(op, req, data)
+	})
+
+	// authenticate once per url and only to target url origin, main document fails if credentials are missing or rejected
+	authenticated := make(map[string]bool)
+	networkAccessManager.ConnectAuthenticationRequired(func(reply *network.QNetworkReply, authenticator *network.QAuthenticator) {
+		u := reply.Url().ToString(core.QUrl__None)
+		if p.TargetUser == "" || authenticated[u] || origin(u) != targetOrigin {
+			if act.isMain(u) {
+				act.err = ErrAuth
+			}
+			return
+		}
+
+		authenticated[u] = true
+		authenticator.SetUser(p.TargetUser)
+		authenticator.SetPassword(p.TargetPassword)
+	})
+
+	networkAccessManager.ConnectFinished(func(reply *network.QNetworkReply) {
+		act.pending--
+		act.resources++
+		act.last = time.Now()
+
+		if act.isMain(reply.Url().ToString(core.QUrl__None)) {
+			ok := true
+			act.status = reply.Attribute(network.QNetworkRequest__HttpStatusCodeAttribute).ToInt(&ok)
+
+			// follow main document redirects, status is status of the final document
+			target := reply.Attribute(network.QNetworkRequest__RedirectionTargetAttribute).ToUrl()
+			if !target.IsEmpty() {
+				act.main = reply.Url().Resolved(target).ToString(core.QUrl__None)
+				return
+			}
+
+			if act.err == nil {
+				act.err = replyError(reply)
+			}
+		}
+	})
+
+	if len(p.Cookies) > 0 {
+		networkAccessManager.SetCookieJar(l.cookieJar(p, networkAccessManager))
+	}
+
+	if p.Proxy != "" {
+		networkAccessManager.SetProxy(l.proxy(p.Proxy))
+	}
+
+	return networkAccessManager
+}
+
+// replyError returns render error for network error of reply, HTTP errors are ignored, target error page is rendered
+func replyError(reply *network.QNetworkReply) error {
+	msg := reply.ErrorString()
+
+	switch code := reply.Error(); {
+	case code == network.QNetworkReply__NoError, code == network.QNetworkReply__OperationCanceledError:
+		return nil
+	case code == network.QNetworkReply__HostNotFoundError:
+		return fmt.Errorf("%w: %s", ErrDNS, msg)
+	case code == network.QNetworkReply__ConnectionRefusedError:
+		return fmt.Errorf("%w: %s", ErrConnRefused, msg)
+	case code == network.QNetworkReply__SslHandshakeFailedError:
+		return fmt.Errorf("%w: %s", ErrTLS, msg)
+	case code == network.QNetworkReply__TimeoutError:
+		return fmt.Errorf("%w: %s", ErrLoadTimeout, msg)
+	case code < 200:
+		// connection and proxy errors
+		return fmt.Errorf("%w: %s", ErrNetwork, msg)
+	}
+
+	return nil
+}
+
+// cookieJar returns cookie jar with params cookies
+func (l *Loader) cookieJar(p Params, parent core.QObject_ITF) *network.QNetworkCookieJar {
+	jar := network.NewQNetworkCookieJar(parent)
+
+	for _, c := range p.Cookies {
+		cookie := network.NewQNetworkCookie(core.NewQByteArray2(c.Name, len(c.Name)), core.NewQByteArray2(c.Value, len(c.Value)))
+		cookie.SetDomain(c.Domain)
+		cookie.SetPath(c.Path)
+		cookie.SetSecure(c.Secure)
+		cookie.SetHttpOnly(c.HttpOnly)
+		jar.InsertCookie(cookie)
+	}
+
+	return jar
+}
+
+// proxy returns network proxy for proxy url, http proxy tunnels https targets with CONNECT
+func (l *Loader) proxy(rawurl string) *network.QNetworkProxy {
+	u, _ := url.Parse(rawurl)
+
+	proxyType := network.QNetworkProxy__HttpProxy
+	if u.Scheme == "socks5" {
+		proxyType = network.QNetworkProxy__Socks5Proxy
+	}
+
+	port := proxyPorts[u.Scheme]
+	if u.Port() != "" {
+		port, _ = strconv.Atoi(u.Port())
+	}
+
+	password, _ := u.User.Password()
+
+	return network.NewQNetworkProxy2(proxyType, u.Hostname(), uint16(port), u.User.Username(), password)
+}
+
+// origin returns scheme, host and port of url, host is lowercased as in urls normalized by Qt
+func origin(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return u.Scheme + "://" + strings.ToLower(u.Hostname()) + ":" + port
+}
+
+// sameUrl checks if urls are equal, ignoring fragment and empty path
+func sameUrl(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	for _, u := range []*url.URL{ua, ub} {
+		u.Fragment = ""
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+
+	return origin(a) == origin(b) && ua.RequestURI() == ub.RequestURI()
+}
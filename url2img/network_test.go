@@ -0,0 +1,30 @@
+package url2img
+
+import (
+	"testing"
+)
+
+func TestSameUrl(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"http://example.com", "http://example.com/", true},
+		{"http://Example.COM/", "http://example.com/", true},
+		{"HTTP://example.com/", "http://example.com/", true},
+		{"http://example.com:80/", "http://example.com/", true},
+		{"https://example.com/", "https://example.com:443/", true},
+		{"http://example.com/#top", "http://example.com/", true},
+		{"http://example.com/a?b=1", "http://example.com/a?b=1", true},
+		{"http://example.com/a", "http://example.com/A", false},
+		{"http://example.com/", "https://example.com/", false},
+		{"http://example.com:8080/", "http://example.com/", false},
+		{"http://www.example.com/", "http://example.com/", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameUrl(tt.a, tt.b); got != tt.same {
+			t.Errorf("sameUrl(%q, %q): got %v, expected %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package url2img
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pool timings
+const (
+	pingInterval    = 5 * time.Second
+	restartDelay    = time.Second
+	maxRestartDelay = time.Minute
+	minUptime       = 10 * time.Second
+	resultGrace     = 500 * time.Millisecond
+)
+
+// Pool represents supervisor of renderer worker processes, workers that crash, hang or exceed memory limit are restarted
+type Pool struct {
+	sync.Mutex
+
+	// MaxMemory is worker resident memory limit in bytes, if 0 memory is not limited
+	MaxMemory int64
+	// HangTimeout is time after which worker that does not answer pings is killed
+	HangTimeout time.Duration
+
+	size    int
+	command string
+	args    []string
+	workers []*worker
+}
+
+// worker represents renderer worker process
+type worker struct {
+	sync.Mutex
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	wmu     sync.Mutex
+	pending map[string]chan Result
+	pong    time.Time
+	started time.Time
+	ready   chan bool
+	retired bool
+	done    chan bool
+}
+
+// NewPool returns new pool of size workers, worker processes are started with command and args
+func NewPool(size int, command string, args ...string) *Pool {
+	return &Pool{HangTimeout: 30 * time.Second, size: size, command: command, args: args}
+}
+
+// Start starts workers, fails if worker can not be started or exits before it answers first ping
+func (p *Pool) Start() error {
+	p.workers = make([]*worker, p.size)
+
+	for i := range p.workers {
+		w, err := p.spawn()
+		if err != nil {
+			p.kill()
+			return err
+		}
+
+		p.workers[i] = w
+	}
+
+	for _, w := range p.workers {
+		if err := w.wait(p.HangTimeout); err != nil {
+			p.kill()
+			return err
+		}
+	}
+
+	for i := range p.workers {
+		go p.supervise(i)
+	}
+
+	return nil
+}
+
+// kill kills started workers
+func (p *Pool) kill() {
+	for _, w := range p.workers {
+		if w != nil {
+			w.cmd.Process.Kill()
+		}
+	}
+}
+
+// Render sends params to least busy worker and waits for result, render is canceled in worker when ctx is done
+func (p *Pool) Render(ctx context.Context, params Params) Result {
+	w := p.pick()
+	if w == nil {
+		return Result{Err: fmt.Errorf("%w: no worker available", ErrWorker)}
+	}
+
+	// worker deadline is earlier than ctx deadline, so timeout error is mapped by worker as in process
+	timeout := time.Duration(0)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline) - resultGrace
+		if timeout <= 0 {
+			timeout = time.Millisecond
+		}
+	}
+
+	c, err := w.send(params, timeout)
+	if err != nil {
+		return Result{Err: fmt.Errorf("%w: %s", ErrWorker, err.Error())}
+	}
+
+	select {
+	case r := <-c:
+		return r
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			// result of timed out render can still be on the way
+			select {
+			case r := <-c:
+				return r
+			case <-time.After(resultGrace):
+			}
+		}
+
+		w.cancel(params.Id)
+		return Result{Err: contextError(ctx)}
+	}
+}
+
+// pick returns running worker with least pending renders
+func (p *Pool) pick() *worker {
+	p.Lock()
+	defer p.Unlock()
+
+	var best *worker
+	min := -1
+
+	for _, w := range p.workers {
+		if w == nil || !w.running() {
+			continue
+		}
+
+		if n := w.load(); min == -1 || n < min {
+			best, min = w, n
+		}
+	}
+
+	return best
+}
+
+// supervise pings worker in slot i, and restarts it when it exits, hangs or exceeds memory limit.
+// Workers that exit soon after start are restarted with exponential backoff.
+func (p *Pool) supervise(i int) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	delay := restartDelay
+
+	for {
+		p.Lock()
+		w := p.workers[i]
+		p.Unlock()
+
+		select {
+		case <-w.done:
+			if time.Since(w.started) < minUptime {
+				fmt.Fprintf(os.Stderr, "Worker %d exited after start, restarting in %s\n", w.cmd.Process.Pid, delay)
+				time.Sleep(delay)
+
+				delay *= 2
+				if delay > maxRestartDelay {
+					delay = maxRestartDelay
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "Worker %d exited, restarting\n", w.cmd.Process.Pid)
+				delay = restartDelay
+			}
+
+			p.restart(i)
+		case <-ticker.C:
+			if time.Since(w.lastPong()) > p.HangTimeout {
+				fmt.Fprintf(os.Stderr, "Worker %d is not responding, killing\n", w.cmd.Process.Pid)
+				w.cmd.Process.Kill()
+				continue
+			}
+
+			if p.MaxMemory > 0 {
+				if rss, err := rss(w.cmd.Process.Pid); err == nil && rss > p.MaxMemory {
+					fmt.Fprintf(os.Stderr, "Worker %d uses %d MB, restarting\n", w.cmd.Process.Pid, rss>>20)
+					w.retire()
+					go w.drain()
+					p.restart(i)
+					continue
+				}
+			}
+
+			w.ping()
+		}
+	}
+}
+
+// restart starts new worker in slot i, retries until worker is started
+func (p *Pool) restart(i int) {
+	for {
+		w, err := p.spawn()
+		if err == nil {
+			p.Lock()
+			p.workers[i] = w
+			p.Unlock()
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "Worker: %s\n", err.Error())
+		time.Sleep(restartDelay)
+	}
+}
+
+// spawn starts worker process
+func (p *Pool) spawn() (*worker, error) {
+	cmd := exec.Command(p.command, p.args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &worker{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan Result),
+		pong:    time.Now(),
+		started: time.Now(),
+		ready:   make(chan bool),
+		done:    make(chan bool),
+	}
+
+	go w.read(stdout)
+
+	return w, nil
+}
+
+// read reads frames from worker, pending renders fail when worker exits
+func (w *worker) read(stdout io.Reader) {
+	br := bufio.NewReader(stdout)
+
+	for {
+		f, payload, err := readFrame(br)
+		if err != nil {
+			break
+		}
+
+		switch f.Type {
+		case framePong:
+			w.Lock()
+			w.pong = time.Now()
+			select {
+			case <-w.ready:
+			default:
+				close(w.ready)
+			}
+			w.Unlock()
+		case frameResult:
+			w.Lock()
+			c, ok := w.pending[f.Id]
+			delete(w.pending, f.Id)
+			w.Unlock()
+
+			if !ok {
+				continue
+			}
+
+			r := Result{Data: payload}
+			if f.Error != "" {
+				r = Result{Err: codeError(f.Code, f.Error)}
+			}
+			if f.Meta != nil {
+				r.Meta = *f.Meta
+			}
+
+			c <- r
+		}
+	}
+
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+
+	w.Lock()
+	for id, c := range w.pending {
+		c <- Result{Err: fmt.Errorf("%w: worker exited", ErrWorker)}
+		delete(w.pending, id)
+	}
+	w.Unlock()
+
+	close(w.done)
+}
+
+// send sends render request to worker
+func (w *worker) send(p Params, timeout time.Duration) (chan Result, error) {
+	d, err := p.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan Result, 1)
+
+	w.Lock()
+	w.pending[p.Id] = c
+	w.Unlock()
+
+	err = w.write(frame{Type: frameRender, Id: p.Id, Timeout: int64(timeout / time.Millisecond)}, []byte(d))
+	if err != nil {
+		w.forget(p.Id)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// write writes frame to worker
+func (w *worker) write(f frame, payload []byte) error {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+
+	return writeFrame(w.stdin, f, payload)
+}
+
+// wait pings worker and waits until it answers, returns error if worker exits or does not answer within timeout
+func (w *worker) wait(timeout time.Duration) error {
+	w.ping()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-w.done:
+		return fmt.Errorf("worker %d exited during startup", w.cmd.Process.Pid)
+	case <-time.After(timeout):
+		return fmt.Errorf("worker %d is not responding", w.cmd.Process.Pid)
+	}
+}
+
+// ping sends ping to worker, worker answers from its main loop
+func (w *worker) ping() {
+	go w.write(frame{Type: framePing, Id: strconv.FormatInt(time.Now().UnixNano(), 10)}, nil)
+}
+
+// cancel removes pending render and cancels it in worker
+func (w *worker) cancel(id string) {
+	w.forget(id)
+
+	go w.write(frame{Type: frameCancel, Id: id}, nil)
+}
+
+// forget removes pending render
+func (w *worker) forget(id string) {
+	w.Lock()
+	defer w.Unlock()
+
+	delete(w.pending, id)
+}
+
+// load returns number of pending renders
+func (w *worker) load() int {
+	w.Lock()
+	defer w.Unlock()
+
+	return len(w.pending)
+}
+
+// lastPong returns time of last ping answer
+func (w *worker) lastPong() time.Time {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.pong
+}
+
+// running checks if worker accepts renders
+func (w *worker) running() bool {
+	select {
+	case <-w.done:
+		return false
+	default:
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	return !w.retired
+}
+
+// retire stops sending renders to worker
+func (w *worker) retire() {
+	w.Lock()
+	defer w.Unlock()
+
+	w.retired = true
+}
+
+// drain waits for pending renders and stops worker
+func (w *worker) drain() {
+	for w.load() > 0 {
+		select {
+		case <-w.done:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+
+	w.stdin.Close()
+
+	select {
+	case <-w.done:
+	case <-time.After(pingInterval):
+		w.cmd.Process.Kill()
+	}
+}
+
+// rss returns resident memory of process in bytes
+func rss(pid int) (int64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("VmRSS:")) {
+			continue
+		}
+
+		fields := bytes.Fields(line[len("VmRSS:"):])
+		if len(fields) == 0 {
+			break
+		}
+
+		kb, err := strconv.ParseInt(string(fields[0]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		return kb << 10, nil
+	}
+
+	return 0, fmt.Errorf("no VmRSS for process %d", pid)
+}
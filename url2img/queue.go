@@ -0,0 +1,123 @@
+package url2img
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Queue represents FIFO render queue, at most max renders run concurrently and at most size wait in queue
+type Queue struct {
+	sync.Mutex
+
+	max     int
+	size    int
+	active  int
+	waiting []chan bool
+}
+
+// QueueStatus represents queue depth
+type QueueStatus struct {
+	Active        int `json:"active"`
+	Queued        int `json:"queued"`
+	MaxConcurrent int `json:"max_concurrent"`
+	QueueSize     int `json:"queue_size"`
+}
+
+// NewQueue returns new queue, if max is 0 number of concurrent renders is not limited
+func NewQueue(max, size int) *Queue {
+	return &Queue{max: max, size: size}
+}
+
+// Acquire waits for free render slot, returns ErrQueueFull if queue is full, or context error if ctx is done before slot is free
+func (q *Queue) Acquire(ctx context.Context) error {
+	c, err := q.Enter()
+	if err != nil {
+		return err
+	}
+
+	return q.Wait(ctx, c)
+}
+
+// Enter takes place in queue without waiting, returns ErrQueueFull if queue is full.
+// Returned place must be passed to Wait.
+func (q *Queue) Enter() (chan bool, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	c := make(chan bool, 1)
+
+	if q.max <= 0 || (q.active < q.max && len(q.waiting) == 0) {
+		q.active++
+		c <- true
+		return c, nil
+	}
+
+	if len(q.waiting) >= q.size {
+		return nil, ErrQueueFull
+	}
+
+	q.waiting = append(q.waiting, c)
+	return c, nil
+}
+
+// Wait waits for render slot of place taken with Enter, returns context error if ctx is done before slot is free
+func (q *Queue) Wait(ctx context.Context, c chan bool) error {
+	select {
+	case <-c:
+		return nil
+	case <-ctx.Done():
+		q.Lock()
+		defer q.Unlock()
+
+		for i, w := range q.waiting {
+			if w == c {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				return contextError(ctx)
+			}
+		}
+
+		// slot was handed over after ctx was done, pass it on
+		q.release()
+		return contextError(ctx)
+	}
+}
+
+// Release frees render slot, slot is handed over to the first waiting render
+func (q *Queue) Release() {
+	q.Lock()
+	defer q.Unlock()
+
+	q.release()
+}
+
+// release frees render slot, queue must be locked
+func (q *Queue) release() {
+	if len(q.waiting) > 0 {
+		c := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		c <- true
+		return
+	}
+
+	q.active--
+}
+
+// Status returns queue status
+func (q *Queue) Status() QueueStatus {
+	q.Lock()
+	defer q.Unlock()
+
+	return QueueStatus{q.active, len(q.waiting), q.max, q.size}
+}
+
+// serveStatus handles /status requests
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, fmt.Errorf("%w: %s", errMethod, r.Method))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.queue.Status())
+}
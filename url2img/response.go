@@ -39,3 +39,8 @@ func (w *responseWriter) Size() int {
 func (w *responseWriter) Status() int {
 	return w.status
 }
+
+// Unwrap returns underlying http.ResponseWriter, used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,13 +1,17 @@
 package url2img
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"html/template"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -18,18 +22,71 @@ import (
 
 // Server represents HTTP server
 type Server struct {
-	Bind         string
-	Auth         *auth.BasicAuth
-	LogFile      *os.File
-	LogFilePath  string
-	CacheDir     string
-	Htpasswd     string
-	MaxAge       int
-	ReadTimeout  int
-	WriteTimeout int
-	Loader       *Loader
+	Bind            string
+	Auth            *auth.BasicAuth
+	LogFile         *os.File
+	LogFilePath     string
+	CacheDir        string
+	Htpasswd        string
+	MaxAge          int
+	ReadTimeout     int
+	WriteTimeout    int
+	JobTimeout      int
+	JobTTL          int
+	MaxJobs         int
+	MaxBatch        int
+	TemplatesDir    string
+	MaxConcurrent   int
+	QueueSize       int
+	Proxy           string
+	StrictTLS       bool
+	Filter          *Filter
+	CallbackSecret  string
+	CallbackRetries int
+	Renderer        Renderer
+
+	jobs      *Jobs
+	queue     *Queue
+	templates *template.Template
 }
 
+// Renderer renders page to image data
+type Renderer interface {
+	// Render loads page and waits for result, render is canceled when ctx is done
+	Render(ctx context.Context, p Params) Result
+}
+
+// Result represents render result
+type Result struct {
+	Data []byte
+	Err  error
+	Meta Meta
+}
+
+// Meta represents rendered page metadata
+type Meta struct {
+	Url           string   `json:"url"`
+	Title         string   `json:"title"`
+	Status        int      `json:"status"`
+	ContentHeight int      `json:"content_height"`
+	LoadTime      int64    `json:"load_time"`
+	Resources     int      `json:"resources"`
+	ConsoleErrors []string `json:"console_errors"`
+}
+
+// jsonOutput represents json output, image is base64 encoded
+type jsonOutput struct {
+	Id          string `json:"id"`
+	Format      string `json:"format"`
+	ContentType string `json:"content_type"`
+	Image       string `json:"image"`
+
+	Meta
+}
+
+// TargetStatusHeader is response header with HTTP status of target page
+const TargetStatusHeader = "X-Target-Status"
+
 // NewServer returns new Server
 func NewServer() *Server {
 	return &Server{}
@@ -43,55 +100,138 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "GET", "HEAD":
 		err := p.FormValues(r)
 		if err != nil {
-			msg := fmt.Sprintf("400 Bad Request (%s)", err.Error())
-			http.Error(w, msg, http.StatusBadRequest)
+			writeError(w, fmt.Errorf("%w: %s", errParams, err.Error()))
 			return
 		}
 	case "POST":
 		err := p.BodyValues(r)
 		if err != nil {
-			msg := fmt.Sprintf("400 Bad Request (%s)", err.Error())
-			http.Error(w, msg, http.StatusBadRequest)
+			writeError(w, fmt.Errorf("%w: %s", errParams, err.Error()))
 			return
 		}
 	default:
-		msg := fmt.Sprintf("405 Method Not Allowed (%s)", r.Method)
-		http.Error(w, msg, http.StatusMethodNotAllowed)
+		writeError(w, fmt.Errorf("%w: %s", errMethod, r.Method))
 		return
 	}
 
-	d, err := p.Marshal()
-	if err != nil {
-		msg := fmt.Sprintf("500 Internal Server Error (%s)", err.Error())
-		http.Error(w, msg, http.StatusInternalServerError)
+	s.serve(w, r, p)
+}
+
+// serve renders params and writes image data or error, render is canceled when client disconnects
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, p Params) {
+	if err := s.checkCallback(p); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	s.Loader.Load(d)
+	res := s.render(r.Context(), p, time.Duration(s.ReadTimeout+s.WriteTimeout)*time.Second)
+	if p.Callback != "" {
+		go s.callback(p, res.Data, res.Err)
+	}
 
-	if !s.wait(p.Id) {
-		msg := fmt.Sprintf("408 Request Timeout (after %d seconds)", s.ReadTimeout+s.WriteTimeout)
-		http.Error(w, msg, http.StatusRequestTimeout)
+	if res.Err == errTimeout {
+		res.Err = fmt.Errorf("%w after %d seconds", errTimeout, s.ReadTimeout+s.WriteTimeout)
+	}
+
+	if res.Err != nil {
+		writeResultError(w, res)
 		return
 	}
 
-	str, _ := s.Loader.Map.Load(p.Id)
-	s.Loader.Map.Delete(p.Id)
+	s.write(w, p, res)
+}
 
-	data, err := hex.DecodeString(str.(string))
-	if err != nil {
-		msg := fmt.Sprintf("500 Internal Server Error (%s)", err.Error())
-		http.Error(w, msg, http.StatusInternalServerError)
-		return
+// render waits in queue, loads page and waits for image data, timeouts after timeout or when ctx is done
+func (s *Server) render(ctx context.Context, p Params, timeout time.Duration) Result {
+	if err := s.prepare(&p); err != nil {
+		return Result{Err: err}
 	}
 
-	if strings.HasPrefix(string(data), "Err") {
-		msg := fmt.Sprintf("500 Internal Server Error (%s)", string(data))
-		http.Error(w, msg, http.StatusInternalServerError)
-		return
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := s.queue.Acquire(ctx); err != nil {
+		return Result{Err: err}
+	}
+	defer s.queue.Release()
+
+	return s.Renderer.Render(ctx, p)
+}
+
+// prepare sets params proxy and tls defaults and checks params with filter
+func (s *Server) prepare(p *Params) error {
+	if p.Proxy == "" {
+		p.Proxy = s.Proxy
+	}
+
+	if s.Filter != nil {
+		if err := s.check(*p); err != nil {
+			return err
+		}
+	}
+
+	if p.TLS == "" {
+		p.TLS = "ignore"
+		if s.StrictTLS {
+			p.TLS = "strict"
+		}
+	}
+
+	return nil
+}
+
+// check checks params page url and proxy with filter
+func (s *Server) check(p Params) error {
+	if u := p.PageUrl(); u != "" {
+		if err := s.Filter.Check(u, p.Proxy == ""); err != nil {
+			return err
+		}
+	}
+
+	if p.Proxy != s.Proxy {
+		u, err := url.Parse(p.Proxy)
+		if err != nil {
+			return err
+		}
+
+		if err := s.Filter.CheckHost(u.Hostname(), true); err != nil {
+			if errors.Is(err, ErrBlocked) {
+				return fmt.Errorf("proxy %w", err)
+			}
+
+			return fmt.Errorf("%w: proxy %s", ErrNetwork, err.Error())
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// checkCallback checks params callback url with filter, it must be checked before render so blocked callback is never posted to
+func (s *Server) checkCallback(p Params) error {
+	if s.Filter == nil || p.Callback == "" {
+		return nil
+	}
+
+	if err := s.Filter.Check(p.Callback, true); err != nil {
+		return fmt.Errorf("callback %w", err)
+	}
+
+	return nil
+}
+
+// write writes image data in requested output
+func (s *Server) write(w http.ResponseWriter, p Params, res Result) {
+	data := res.Data
+	f := formats[p.Format]
+	name := p.Url
+	if name == "" {
+		name = "page"
+	}
+	filename := name + "." + f.Extension
+
+	if res.Meta.Status != 0 {
+		w.Header().Set(TargetStatusHeader, strconv.Itoa(res.Meta.Status))
+	}
 
 	if s.CacheDir != "" {
 		w.Header().Set("Cache-Control", fmt.Sprintf("public,max-age=%d", s.MaxAge))
@@ -100,20 +240,36 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	switch p.Output {
 	case "raw":
-		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.%s\"", p.Url, p.Format))
+		w.Header().Set("Content-Type", f.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
+		w.WriteHeader(http.StatusOK)
 		w.Write(data)
 	case "base64":
 		b64 := base64.StdEncoding.EncodeToString(data)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(b64))
 	case "html":
-		html := "<!DOCTYPE html><html><body><img src=\"data:image/%s;base64,%s\" download\"%s\"/></body></html>"
-		html = fmt.Sprintf(html, p.Format, base64.StdEncoding.EncodeToString(data), p.Url+"."+p.Format)
+		html := "<!DOCTYPE html><html><body><img src=\"data:%s;base64,%s\" download=\"%s\"/></body></html>"
+		if p.Format == "pdf" {
+			html = "<!DOCTYPE html><html><body><embed src=\"data:%s;base64,%s\" type=\"application/pdf\" width=\"100%%\" height=\"100%%\" title=\"%s\"/></body></html>"
+		}
+		html = fmt.Sprintf(html, f.ContentType, base64.StdEncoding.EncodeToString(data), filename)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(html))
+	case "json":
+		writeJSON(w, http.StatusOK, jsonOutput{p.Id, p.Format, f.ContentType, base64.StdEncoding.EncodeToString(data), res.Meta})
 	}
 }
 
 // ListenAndServe listens on the TCP address and serves requests
 func (s *Server) ListenAndServe() {
+	if s.Proxy != "" && !validProxy(s.Proxy) {
+		fmt.Fprintf(os.Stderr, "invalid proxy %s\n", s.Proxy)
+		os.Exit(6)
+	}
+
 	http.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
@@ -134,6 +290,25 @@ func (s *Server) ListenAndServe() {
 		}
 	}()
 
+	s.queue = NewQueue(s.MaxConcurrent, s.QueueSize)
+	http.Handle("/status", newHandler(http.HandlerFunc(s.serveStatus), s.LogFile, s.Auth))
+
+	s.jobs = NewJobs(time.Duration(s.JobTTL)*time.Second, s.MaxJobs)
+	http.Handle("/jobs", newHandler(http.HandlerFunc(s.serveJobs), s.LogFile, s.Auth))
+	http.Handle("/jobs/", newHandler(http.HandlerFunc(s.serveJobs), s.LogFile, s.Auth))
+	http.Handle("/batch", newHandler(http.HandlerFunc(s.serveBatch), s.LogFile, s.Auth))
+
+	if s.TemplatesDir != "" {
+		templates, err := parseTemplates(s.TemplatesDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(7)
+		}
+
+		s.templates = templates
+		http.Handle("/render/", newHandler(http.HandlerFunc(s.serveTemplate), s.LogFile, s.Auth))
+	}
+
 	if s.CacheDir != "" {
 		cache, err := httpcache.NewDiskCache(s.CacheDir)
 		if err != nil {
@@ -161,28 +336,6 @@ func (s *Server) ListenAndServe() {
 	srv.Serve(listener)
 }
 
-// wait waits for url to load, timeouts after ReadTimeout+WriteTimeout
-func (s *Server) wait(id string) bool {
-	end := make(chan bool, 1)
-	timeout := time.After(time.Duration(s.ReadTimeout+s.WriteTimeout) * time.Second)
-
-	for {
-		_, ok := s.Loader.Map.Load(id)
-		select {
-		case <-end:
-			return true
-		case <-timeout:
-			return false
-		default:
-			if ok {
-				end <- true
-			}
-		}
-
-		time.Sleep(10 * time.Millisecond)
-	}
-}
-
 // open opens log and htpasswd file
 func (s *Server) open() {
 	if s.Htpasswd != "" {
@@ -0,0 +1,91 @@
+package url2img
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// templateExt is extension of template files
+const templateExt = ".html"
+
+// parseTemplates parses html templates in dir, templates are named by file name without extension
+func parseTemplates(dir string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no templates in %s", dir)
+	}
+
+	t := template.New("")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), templateExt)
+
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = t.New(name).Parse(string(text))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// serveTemplate handles /render/{template} requests
+func (s *Server) serveTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, fmt.Errorf("%w: %s", errMethod, r.Method))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/render/")
+
+	t := s.templates.Lookup(name)
+	if name == "" || t == nil {
+		writeError(w, fmt.Errorf("%w: template %s", errNotFound, name))
+		return
+	}
+
+	var data interface{}
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil && err != io.EOF {
+		writeError(w, fmt.Errorf("%w: %s", errParams, err.Error()))
+		return
+	}
+
+	p := NewParams()
+	err = p.TemplateValues(r)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %s", errParams, err.Error()))
+		return
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, data)
+	if err != nil {
+		writeError(w, fmt.Errorf("%w: %s", errTemplate, err.Error()))
+		return
+	}
+
+	if buf.Len() > maxHtml {
+		writeError(w, fmt.Errorf("%w: html maximum is %d bytes", errTemplate, maxHtml))
+		return
+	}
+
+	p.Html = buf.String()
+
+	s.serve(w, r, p)
+}
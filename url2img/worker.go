@@ -0,0 +1,156 @@
+package url2img
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Worker frame types
+const (
+	frameRender = "render"
+	frameResult = "result"
+	framePing   = "ping"
+	framePong   = "pong"
+	frameCancel = "cancel"
+)
+
+// maxFrame is maximum frame payload size
+const maxFrame = 256 << 20
+
+// frame represents worker pipe message, json header line is followed by size bytes of payload
+type frame struct {
+	Type    string `json:"type"`
+	Id      string `json:"id"`
+	Timeout int64  `json:"timeout,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Size    int    `json:"size,omitempty"`
+	Meta    *Meta  `json:"meta,omitempty"`
+}
+
+// ServeWorker reads render requests from r and writes results to w, returns when r is closed.
+// Loader main loop must be running.
+func ServeWorker(l *Loader, r io.Reader, w io.Writer) error {
+	var mu sync.Mutex
+	write := func(f frame, payload []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		writeFrame(w, f, payload)
+	}
+
+	l.ConnectPing(func(id string) {
+		write(frame{Type: framePong, Id: id}, nil)
+	})
+
+	var cmu sync.Mutex
+	cancels := make(map[string]context.CancelFunc)
+
+	br := bufio.NewReader(r)
+
+	for {
+		f, payload, err := readFrame(br)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch f.Type {
+		case framePing:
+			l.Ping(f.Id)
+		case frameCancel:
+			cmu.Lock()
+			if cancel, ok := cancels[f.Id]; ok {
+				cancel()
+			}
+			cmu.Unlock()
+		case frameRender:
+			ctx, cancel := context.WithCancel(context.Background())
+			if f.Timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), time.Duration(f.Timeout)*time.Millisecond)
+			}
+
+			cmu.Lock()
+			cancels[f.Id] = cancel
+			cmu.Unlock()
+
+			go func(f frame, payload []byte) {
+				defer func() {
+					cmu.Lock()
+					delete(cancels, f.Id)
+					cmu.Unlock()
+
+					cancel()
+				}()
+
+				p := NewParams()
+
+				var r Result
+				if err := p.Unmarshal(string(payload)); err != nil {
+					r.Err = err
+				} else {
+					r = l.Render(ctx, p)
+				}
+
+				// meta is sent with errors too, e.g. target status of page rejected with fail_on_status
+				res := frame{Type: frameResult, Id: f.Id, Meta: &r.Meta}
+				if r.Err != nil {
+					res.Code, res.Error = errorCode(r.Err), r.Err.Error()
+					r.Data = nil
+				}
+
+				write(res, r.Data)
+			}(f, payload)
+		}
+	}
+}
+
+// writeFrame writes frame header and payload
+func writeFrame(w io.Writer, f frame, payload []byte) error {
+	f.Size = len(payload)
+
+	header, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, len(header)+1+len(payload))
+	buf = append(buf, header...)
+	buf = append(buf, '\n')
+	buf = append(buf, payload...)
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// readFrame reads frame header and payload
+func readFrame(r *bufio.Reader) (f frame, payload []byte, err error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(line, &f)
+	if err != nil {
+		return
+	}
+
+	if f.Size < 0 || f.Size > maxFrame {
+		err = fmt.Errorf("invalid frame size %d", f.Size)
+		return
+	}
+
+	payload = make([]byte, f.Size)
+	_, err = io.ReadFull(r, payload)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return
+}